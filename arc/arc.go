@@ -0,0 +1,135 @@
+// Package arc creates and validates the Authenticated Received Chain
+// (ARC), as specified in RFC 8617, on top of the canonicalization and
+// signing primitives exported by the dkim package.
+package arc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jensbjorgensen/go-msgauth/dkim"
+)
+
+// instanceRegexp matches the "i=" tag that every ARC header field (whether
+// a strict tag-list like ARC-Message-Signature/ARC-Seal, or the
+// loosely-structured ARC-Authentication-Results) always carries first.
+var instanceRegexp = regexp.MustCompile(`^\s*i\s*=\s*(\d+)`)
+
+// parseInstance extracts the "i=" tag from a raw ARC header field.
+func parseInstance(raw string) (int, error) {
+	i := strings.IndexByte(raw, ':')
+	if i < 0 {
+		return 0, fmt.Errorf("arc: malformed header field: %q", raw)
+	}
+	value := strings.ReplaceAll(raw[i+1:], "\r\n", "")
+	m := instanceRegexp.FindStringSubmatch(value)
+	if m == nil {
+		return 0, fmt.Errorf("arc: missing i= tag in %q", raw)
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, fmt.Errorf("arc: malformed i= tag: %w", err)
+	}
+	return n, nil
+}
+
+func b64(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+func splitColon(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ":")
+}
+
+// ArcChainStatus is the validation status of an ARC chain, used to
+// populate the "cv=" tag of a new ARC-Seal header field.
+type ArcChainStatus string
+
+const (
+	ChainNone ArcChainStatus = "none"
+	ChainPass ArcChainStatus = "pass"
+	ChainFail ArcChainStatus = "fail"
+)
+
+// Verification is the result of validating a single ARC set (i.e. a single
+// hop) in the chain.
+type Verification = dkim.Verification
+
+const (
+	headerAAR = "arc-authentication-results"
+	headerAMS = "arc-message-signature"
+	headerAS  = "arc-seal"
+)
+
+// set groups the three ARC header fields that share the same "i=" instance
+// number.
+type set struct {
+	instance     int
+	aar, ams, as string
+}
+
+// chainSets extracts the ARC header sets present in headers, sorted by
+// instance number (oldest, i.e. first hop, first).
+func chainSets(headers []string) ([]*set, error) {
+	byInstance := make(map[int]*set)
+	var instances []int
+
+	// assign records raw under the field of its instance's set selected by
+	// field, returning an error if that instance already has one: two
+	// header fields of the same ARC type claiming the same "i=" would
+	// otherwise let a tampered, duplicated instance silently overwrite the
+	// legitimate one.
+	assign := func(raw, fieldName string, field func(s *set) *string) error {
+		i, err := parseInstance(raw)
+		if err != nil {
+			return err
+		}
+		s, ok := byInstance[i]
+		if !ok {
+			s = &set{instance: i}
+			byInstance[i] = s
+			instances = append(instances, i)
+		}
+		f := field(s)
+		if *f != "" {
+			return fmt.Errorf("arc: duplicate %s header field for i=%d", fieldName, i)
+		}
+		*f = raw
+		return nil
+	}
+
+	for _, raw := range headers {
+		i := strings.IndexByte(raw, ':')
+		if i < 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(raw[:i]))
+
+		var err error
+		switch name {
+		case headerAAR:
+			err = assign(raw, "ARC-Authentication-Results", func(s *set) *string { return &s.aar })
+		case headerAMS:
+			err = assign(raw, "ARC-Message-Signature", func(s *set) *string { return &s.ams })
+		case headerAS:
+			err = assign(raw, "ARC-Seal", func(s *set) *string { return &s.as })
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Ints(instances)
+	sets := make([]*set, len(instances))
+	for idx, i := range instances {
+		sets[idx] = byInstance[i]
+	}
+	return sets, nil
+}
@@ -0,0 +1,270 @@
+package arc
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jensbjorgensen/go-msgauth/dkim"
+)
+
+// now returns the current time. It's a variable so that tests can pin the
+// value used for the "t=" tags.
+var now = time.Now
+
+// SealOptions is used to configure Seal.
+type SealOptions struct {
+	// Domain is the domain sealing the message, used to populate the "d="
+	// tag of the ARC-Message-Signature and ARC-Seal header fields.
+	// Required.
+	Domain string
+	// Selector is the ARC selector, used to populate the "s=" tag.
+	// Required.
+	Selector string
+	// Signer is the private key used to sign the ARC-Message-Signature and
+	// ARC-Seal header fields. It must be either an *rsa.PrivateKey or an
+	// ed25519.PrivateKey, just like dkim.SignOptions.Signer. Required.
+	Signer crypto.Signer
+
+	// Hash is the hash function used for the ARC-Message-Signature and
+	// ARC-Seal header fields. Defaults to crypto.SHA256.
+	Hash crypto.Hash
+
+	// HeaderCanonicalization is the canonicalization algorithm for the
+	// ARC-Message-Signature header. Defaults to "relaxed". ARC-Seal always
+	// uses "relaxed", per RFC 8617 Section 4.1.3.
+	HeaderCanonicalization string
+	// BodyCanonicalization is the canonicalization algorithm for the body.
+	// Defaults to "relaxed".
+	BodyCanonicalization string
+	// HeaderKeys is the list of header fields to sign in the
+	// ARC-Message-Signature, used to populate its "h=" tag. If empty, all
+	// ordinary (non-ARC) header fields present in the message are signed.
+	HeaderKeys []string
+
+	// AuthenticationResults is the value to place in the new
+	// ARC-Authentication-Results header field, after its "i=" tag (e.g.
+	// "mx.example.org; dkim=pass; spf=pass"). Required.
+	AuthenticationResults string
+
+	// Resolver is used to look up the public keys needed to validate the
+	// existing ARC chain (if any) before sealing it further. Defaults to
+	// dkim.DefaultResolver.
+	Resolver dkim.Resolver
+}
+
+func (options *SealOptions) hash() crypto.Hash {
+	if options.Hash != 0 {
+		return options.Hash
+	}
+	return crypto.SHA256
+}
+
+func (options *SealOptions) headerCanonicalization() string {
+	if options.HeaderCanonicalization != "" {
+		return options.HeaderCanonicalization
+	}
+	return dkim.CanonicalizationRelaxed
+}
+
+func (options *SealOptions) bodyCanonicalization() string {
+	if options.BodyCanonicalization != "" {
+		return options.BodyCanonicalization
+	}
+	return dkim.CanonicalizationRelaxed
+}
+
+// Seal validates the existing ARC chain (if any) in a message read from r,
+// then adds a new ARC-Authentication-Results, ARC-Message-Signature and
+// ARC-Seal header set on top of it, writing the result to w. It's a
+// shorthand for SealWithContext with a background context.
+//
+// The message's body and ordinary header fields are hashed and signed by
+// the ARC-Message-Signature, exactly like dkim.Sign does for
+// DKIM-Signature. The ARC-Seal then signs the complete, ordered set of ARC
+// header fields seen so far (including the one just added), so that a
+// later validator can detect any ARC set being reordered, altered or
+// removed.
+func Seal(w io.Writer, r io.Reader, options *SealOptions) error {
+	return SealWithContext(context.Background(), w, r, options)
+}
+
+// SealWithContext is like Seal, using ctx to bound or cancel the DNS
+// lookups made to validate the existing ARC chain before sealing it
+// further.
+func SealWithContext(ctx context.Context, w io.Writer, r io.Reader, options *SealOptions) error {
+	if options == nil {
+		return errors.New("arc: missing options")
+	}
+	if options.Domain == "" {
+		return errors.New("arc: missing domain")
+	}
+	if options.Selector == "" {
+		return errors.New("arc: missing selector")
+	}
+	if options.Signer == nil {
+		return errors.New("arc: missing signer")
+	}
+	if options.AuthenticationResults == "" {
+		return errors.New("arc: missing authentication results")
+	}
+
+	headers, body, err := dkim.ReadMessage(r)
+	if err != nil {
+		return err
+	}
+
+	sets, err := chainSets(headers)
+	if err != nil {
+		return err
+	}
+
+	resolver := dkim.DefaultResolver
+	if options.Resolver != nil {
+		resolver = options.Resolver
+	}
+
+	instance := 1
+	cv := ChainNone
+	if len(sets) > 0 {
+		instance = sets[len(sets)-1].instance + 1
+		cv, err = validateChain(ctx, resolver, sets, headers, body)
+		if err != nil {
+			return err
+		}
+	}
+
+	headerKeys := options.HeaderKeys
+	if len(headerKeys) == 0 {
+		for _, raw := range headers {
+			i := strings.IndexByte(raw, ':')
+			if i < 0 {
+				return fmt.Errorf("arc: malformed header field: %q", raw)
+			}
+			name := strings.ToLower(strings.TrimSpace(raw[:i]))
+			if isArcHeader(name) {
+				continue
+			}
+			headerKeys = append(headerKeys, strings.TrimSpace(raw[:i]))
+		}
+	}
+
+	aarField := dkim.FoldHeader("ARC-Authentication-Results", fmt.Sprintf(" i=%d; %s", instance, options.AuthenticationResults))
+	amsField, err := sealMessageSignature(options, instance, headers, headerKeys, body)
+	if err != nil {
+		return err
+	}
+	asField, err := sealSeal(options, instance, cv, sets, aarField, amsField)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range []string{aarField, amsField, asField} {
+		if _, err := io.WriteString(w, field); err != nil {
+			return err
+		}
+	}
+	for _, raw := range headers {
+		if _, err := io.WriteString(w, raw); err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(w, "\r\n"); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func isArcHeader(name string) bool {
+	switch name {
+	case headerAAR, headerAMS, headerAS:
+		return true
+	default:
+		return false
+	}
+}
+
+func sealMessageSignature(options *SealOptions, instance int, headers []string, headerKeys []string, body []byte) (string, error) {
+	keyAlgo, err := dkim.SignAlgoName(options.Signer)
+	if err != nil {
+		return "", err
+	}
+	algo := dkim.HashAlgoName(keyAlgo, options.hash())
+
+	canonBody, err := dkim.CanonicalizeBody(options.bodyCanonicalization(), body)
+	if err != nil {
+		return "", err
+	}
+	h := options.hash().New()
+	h.Write(canonBody)
+	bh := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	fields, err := dkim.PickHeaders(headers, headerKeys)
+	if err != nil {
+		return "", err
+	}
+
+	tags := []string{
+		fmt.Sprintf("i=%d", instance),
+		"a=" + algo,
+		"bh=" + bh,
+		"c=" + options.headerCanonicalization() + "/" + options.bodyCanonicalization(),
+		"d=" + options.Domain,
+		"h=" + strings.Join(headerKeys, ":"),
+		"s=" + options.Selector,
+		"t=" + fmt.Sprint(now().Unix()),
+	}
+
+	unsignedValue := " " + strings.Join(tags, "; ") + "; b="
+	data, err := dkim.BuildSignedData(options.headerCanonicalization(), fields, "ARC-Message-Signature:"+unsignedValue)
+	if err != nil {
+		return "", err
+	}
+	_, sig, err := dkim.SignDigest(options.Signer, options.hash(), data)
+	if err != nil {
+		return "", err
+	}
+	tags = append(tags, "b="+sig)
+	return dkim.FoldHeader("ARC-Message-Signature", " "+strings.Join(tags, "; ")+";"), nil
+}
+
+func sealSeal(options *SealOptions, instance int, cv ArcChainStatus, sets []*set, aarField, amsField string) (string, error) {
+	keyAlgo, err := dkim.SignAlgoName(options.Signer)
+	if err != nil {
+		return "", err
+	}
+	algo := dkim.HashAlgoName(keyAlgo, options.hash())
+
+	tags := []string{
+		fmt.Sprintf("i=%d", instance),
+		"a=" + algo,
+		"cv=" + string(cv),
+		"d=" + options.Domain,
+		"s=" + options.Selector,
+		"t=" + fmt.Sprint(now().Unix()),
+	}
+
+	var fields []string
+	for _, s := range sets {
+		fields = append(fields, s.aar, s.ams, s.as)
+	}
+	fields = append(fields, aarField, amsField)
+
+	unsignedValue := " " + strings.Join(tags, "; ") + "; b="
+	data, err := dkim.BuildSignedData(dkim.CanonicalizationRelaxed, fields, "ARC-Seal:"+unsignedValue)
+	if err != nil {
+		return "", err
+	}
+	_, sig, err := dkim.SignDigest(options.Signer, options.hash(), data)
+	if err != nil {
+		return "", err
+	}
+	tags = append(tags, "b="+sig)
+	return dkim.FoldHeader("ARC-Seal", " "+strings.Join(tags, "; ")+";"), nil
+}
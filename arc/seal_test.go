@@ -0,0 +1,178 @@
+package arc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jensbjorgensen/go-msgauth/dkim"
+)
+
+const mailString = "From: Joe SixPack <joe@football.example.com>\r\n" +
+	"To: Suzie Q <suzie@shopping.example.net>\r\n" +
+	"Subject: Is dinner ready?\r\n" +
+	"Date: Fri, 11 Jul 2003 21:00:37 -0700 (PDT)\r\n" +
+	"Message-ID: <20030712040037.46341.5F8J@football.example.com>\r\n" +
+	"\r\n" +
+	"Hi.\r\n" +
+	"\r\n" +
+	"We lost the game. Are you hungry yet?\r\n" +
+	"\r\n" +
+	"Joe."
+
+var testKey *rsa.PrivateKey
+var testResolver dkim.Resolver
+
+func init() {
+	var err error
+	testKey, err = rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		panic(err)
+	}
+
+	pub, err := x509.MarshalPKIXPublicKey(&testKey.PublicKey)
+	if err != nil {
+		panic(err)
+	}
+	record := "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(pub)
+	testResolver = dkim.MapResolver{
+		Records: map[string][]string{
+			"seal._domainkey.relay.example.net": {record},
+		},
+		AD: true,
+	}
+
+	now = func() time.Time { return time.Unix(1, 0) }
+}
+
+func TestSeal_firstHop(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SealOptions{
+		Domain:                "relay.example.net",
+		Selector:              "seal",
+		Signer:                testKey,
+		AuthenticationResults: "relay.example.net; dkim=pass",
+		Resolver:              testResolver,
+	}
+
+	var b bytes.Buffer
+	if err := SealWithContext(context.Background(), &b, r, options); err != nil {
+		t.Fatal("Expected no error while sealing mail, got:", err)
+	}
+
+	sealed := b.String()
+	if !strings.Contains(sealed, "ARC-Seal:") || !strings.Contains(sealed, "cv=none") {
+		t.Errorf("Expected a first-hop ARC-Seal with cv=none, got:\n%v", sealed)
+	}
+
+	verifications, status, err := ValidateWithOptions(context.Background(), strings.NewReader(sealed), &ValidateOptions{Resolver: testResolver})
+	if err != nil {
+		t.Fatalf("Expected no error while validating chain, got: %v", err)
+	}
+	if status != ChainPass {
+		t.Errorf("Expected chain status to be %q, got %q", ChainPass, status)
+	}
+	if len(verifications) != 1 {
+		t.Fatalf("Expected exactly one verification, got %d", len(verifications))
+	}
+	if err := verifications[0].Err; err != nil {
+		t.Errorf("Expected no error verifying instance 1, got: %v", err)
+	}
+	if !verifications[0].AuthenticatedData {
+		t.Error("Expected the verification to report DNSSEC authentication from the resolver")
+	}
+}
+
+func TestSeal_secondHop(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SealOptions{
+		Domain:                "relay.example.net",
+		Selector:              "seal",
+		Signer:                testKey,
+		AuthenticationResults: "relay.example.net; dkim=pass",
+		Resolver:              testResolver,
+	}
+
+	var first bytes.Buffer
+	if err := Seal(&first, r, options); err != nil {
+		t.Fatal("Expected no error while sealing mail, got:", err)
+	}
+
+	var second bytes.Buffer
+	if err := Seal(&second, bytes.NewReader(first.Bytes()), options); err != nil {
+		t.Fatal("Expected no error while sealing mail a second time, got:", err)
+	}
+
+	if !strings.Contains(second.String(), "i=2") {
+		t.Errorf("Expected a second ARC set with i=2, got:\n%v", second.String())
+	}
+
+	verifications, status, err := ValidateWithOptions(context.Background(), bytes.NewReader(second.Bytes()), &ValidateOptions{Resolver: testResolver})
+	if err != nil {
+		t.Fatalf("Expected no error while validating chain, got: %v", err)
+	}
+	if status != ChainPass {
+		t.Errorf("Expected chain status to be %q, got %q", ChainPass, status)
+	}
+	if len(verifications) != 2 {
+		t.Fatalf("Expected two verifications, got %d", len(verifications))
+	}
+}
+
+func TestValidate_duplicateInstance(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SealOptions{
+		Domain:                "relay.example.net",
+		Selector:              "seal",
+		Signer:                testKey,
+		AuthenticationResults: "relay.example.net; dkim=pass",
+		Resolver:              testResolver,
+	}
+
+	var b bytes.Buffer
+	if err := Seal(&b, r, options); err != nil {
+		t.Fatal("Expected no error while sealing mail, got:", err)
+	}
+
+	// Duplicate the ARC-Seal header field, still claiming i=1: a tampered
+	// chain that collides an instance number must be rejected outright,
+	// not silently resolved by keeping whichever copy was seen last.
+	headers, body, err := dkim.ReadMessage(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var asField string
+	for _, h := range headers {
+		if strings.HasPrefix(strings.ToLower(h), "arc-seal:") {
+			asField = h
+		}
+	}
+	if asField == "" {
+		t.Fatal("Expected an ARC-Seal header field in the sealed message")
+	}
+	tampered := asField + strings.Join(headers, "") + "\r\n" + string(body)
+
+	_, _, err = ValidateWithOptions(context.Background(), strings.NewReader(tampered), &ValidateOptions{Resolver: testResolver})
+	if err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("Expected a duplicate ARC-Seal error, got: %v", err)
+	}
+}
+
+func TestValidate_noChain(t *testing.T) {
+	verifications, status, err := ValidateWithOptions(context.Background(), strings.NewReader(mailString), &ValidateOptions{Resolver: testResolver})
+	if err != nil {
+		t.Fatalf("Expected no error while validating a message without a chain, got: %v", err)
+	}
+	if status != ChainNone {
+		t.Errorf("Expected chain status to be %q, got %q", ChainNone, status)
+	}
+	if len(verifications) != 0 {
+		t.Errorf("Expected no verifications, got %d", len(verifications))
+	}
+}
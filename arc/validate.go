@@ -0,0 +1,203 @@
+package arc
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/jensbjorgensen/go-msgauth/dkim"
+)
+
+// ValidateOptions is used to configure ValidateWithOptions.
+type ValidateOptions struct {
+	// Resolver is used to look up the public keys of the ARC-Message-
+	// Signature and ARC-Seal header fields in the chain. Defaults to
+	// dkim.DefaultResolver.
+	Resolver dkim.Resolver
+}
+
+// Validate checks the ARC chain in a message read from r, fetching public
+// keys over DNS as needed. It returns one Verification per ARC set found
+// (oldest hop first) along with the chain's overall validation status.
+//
+// A status of ChainNone means the message carries no ARC sets at all. A
+// status of ChainFail means at least one ARC set is missing, out of
+// sequence, or fails signature verification. It's a shorthand for
+// ValidateWithOptions with a background context and the default resolver.
+func Validate(r io.Reader) ([]*Verification, ArcChainStatus, error) {
+	return ValidateWithOptions(context.Background(), r, nil)
+}
+
+// ValidateWithOptions checks the ARC chain in a message read from r, like
+// Validate, using options to control how public keys are resolved.
+func ValidateWithOptions(ctx context.Context, r io.Reader, options *ValidateOptions) ([]*Verification, ArcChainStatus, error) {
+	resolver := dkim.DefaultResolver
+	if options != nil && options.Resolver != nil {
+		resolver = options.Resolver
+	}
+
+	headers, body, err := dkim.ReadMessage(r)
+	if err != nil {
+		return nil, ChainFail, err
+	}
+
+	sets, err := chainSets(headers)
+	if err != nil {
+		return nil, ChainFail, err
+	}
+	if len(sets) == 0 {
+		return nil, ChainNone, nil
+	}
+
+	status := ChainPass
+	verifications := make([]*Verification, len(sets))
+	for idx, s := range sets {
+		v := &Verification{}
+		verifications[idx] = v
+
+		if s.instance != idx+1 || s.aar == "" || s.ams == "" || s.as == "" {
+			v.Err = fmt.Errorf("arc: broken chain at instance %d", s.instance)
+			status = ChainFail
+			continue
+		}
+
+		if tags, terr := dkim.ParseTagList(s.ams); terr == nil {
+			v.Domain = tags["d"]
+			v.Identifier = tags["i"]
+			if hs, ok := tags["h"]; ok {
+				v.HeaderKeys = splitColon(hs)
+			}
+		}
+
+		amsAD, err := verifyAMS(ctx, resolver, s, headers, body)
+		if err == nil {
+			var asAD bool
+			asAD, err = verifyAS(ctx, resolver, sets[:idx+1])
+			v.AuthenticatedData = amsAD && asAD
+		}
+		v.Err = err
+
+		if v.Err != nil {
+			status = ChainFail
+		}
+	}
+
+	return verifications, status, nil
+}
+
+func validateChain(ctx context.Context, resolver dkim.Resolver, sets []*set, headers []string, body []byte) (ArcChainStatus, error) {
+	for idx, s := range sets {
+		if s.instance != idx+1 || s.aar == "" || s.ams == "" || s.as == "" {
+			return ChainFail, nil
+		}
+		if _, err := verifyAMS(ctx, resolver, s, headers, body); err != nil {
+			return ChainFail, nil
+		}
+		if _, err := verifyAS(ctx, resolver, sets[:idx+1]); err != nil {
+			return ChainFail, nil
+		}
+	}
+	return ChainPass, nil
+}
+
+func verifyAMS(ctx context.Context, resolver dkim.Resolver, s *set, headers []string, body []byte) (bool, error) {
+	tags, err := dkim.ParseTagList(s.ams)
+	if err != nil {
+		return false, err
+	}
+
+	domain := tags["d"]
+	selector := tags["s"]
+	algo, hashAlgo, err := dkim.SplitAlgo(tags["a"])
+	if err != nil {
+		return false, err
+	}
+	headerC, bodyC, err := dkim.SplitCanon(tags["c"])
+	if err != nil {
+		return false, err
+	}
+	hash, err := dkim.ParseHashAlgo(hashAlgo)
+	if err != nil {
+		return false, err
+	}
+
+	canonBody, err := dkim.CanonicalizeBody(bodyC, body)
+	if err != nil {
+		return false, err
+	}
+	h := hash.New()
+	h.Write(canonBody)
+	if sum := b64(h.Sum(nil)); sum != tags["bh"] {
+		return false, fmt.Errorf("arc: ARC-Message-Signature body hash does not match")
+	}
+
+	fields, err := dkim.PickHeaders(headers, splitColon(tags["h"]))
+	if err != nil {
+		return false, err
+	}
+
+	unsignedRaw, err := dkim.UnsignHeader(s.ams, tags["b"])
+	if err != nil {
+		return false, err
+	}
+	data, err := dkim.BuildSignedData(headerC, fields, unsignedRaw)
+	if err != nil {
+		return false, err
+	}
+
+	pub, keyAlgo, ad, err := dkim.LookupPublicKeyWithResolver(ctx, resolver, domain, selector)
+	if err != nil {
+		return ad, err
+	}
+	if keyAlgo != algo {
+		return ad, fmt.Errorf("arc: key algorithm %q does not match signature algorithm %q", keyAlgo, algo)
+	}
+	return ad, dkim.VerifyDigest(pub, hash, data, tags["b"])
+}
+
+// verifyAS verifies the ARC-Seal of the newest set in sets (the one being
+// checked), whose signed data is the ordered sequence of every earlier
+// complete ARC set plus this set's ARC-Authentication-Results and
+// ARC-Message-Signature fields.
+func verifyAS(ctx context.Context, resolver dkim.Resolver, sets []*set) (bool, error) {
+	cur := sets[len(sets)-1]
+
+	tags, err := dkim.ParseTagList(cur.as)
+	if err != nil {
+		return false, err
+	}
+	domain := tags["d"]
+	selector := tags["s"]
+	algo, hashAlgo, err := dkim.SplitAlgo(tags["a"])
+	if err != nil {
+		return false, err
+	}
+	hash, err := dkim.ParseHashAlgo(hashAlgo)
+	if err != nil {
+		return false, err
+	}
+
+	var fields []string
+	for _, s := range sets[:len(sets)-1] {
+		fields = append(fields, s.aar, s.ams, s.as)
+	}
+	fields = append(fields, cur.aar, cur.ams)
+
+	unsignedRaw, err := dkim.UnsignHeader(cur.as, tags["b"])
+	if err != nil {
+		return false, err
+	}
+	data, err := dkim.BuildSignedData(dkim.CanonicalizationRelaxed, fields, unsignedRaw)
+	if err != nil {
+		return false, err
+	}
+
+	pub, keyAlgo, ad, err := dkim.LookupPublicKeyWithResolver(ctx, resolver, domain, selector)
+	if err != nil {
+		return ad, err
+	}
+	if keyAlgo != algo {
+		return ad, fmt.Errorf("arc: key algorithm %q does not match signature algorithm %q", keyAlgo, algo)
+	}
+	return ad, dkim.VerifyDigest(pub, hash, data, tags["b"])
+}
@@ -0,0 +1,63 @@
+// Command dkim-keygen generates a DKIM key pair and prints the private key
+// PEM and the DNS TXT record contents needed to publish the selector.
+package main
+
+import (
+	"crypto"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/jensbjorgensen/go-msgauth/dkim/keygen"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "dkim-keygen:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	algo := flag.String("algo", "rsa", `key algorithm: "rsa" or "ed25519"`)
+	bits := flag.Int("bits", 2048, `RSA key size in bits, 2048 or 4096 (ignored for -algo=ed25519)`)
+	selector := flag.String("selector", "", "DKIM selector (required)")
+	domain := flag.String("domain", "", "signing domain, used to print the full record name (required)")
+	out := flag.String("out", "", "file to write the PKCS#8 private key PEM to (default: stdout)")
+	flag.Parse()
+
+	if *selector == "" || *domain == "" {
+		return fmt.Errorf("-selector and -domain are required")
+	}
+
+	var priv crypto.Signer
+	var err error
+	switch *algo {
+	case "rsa":
+		priv, err = keygen.GenerateRSA(*bits)
+	case "ed25519":
+		priv, err = keygen.GenerateEd25519()
+	default:
+		err = fmt.Errorf("unsupported -algo: %q", *algo)
+	}
+	if err != nil {
+		return err
+	}
+
+	pemBytes, err := keygen.MarshalPrivateKeyPEM(priv)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		os.Stdout.Write(pemBytes)
+	} else if err := os.WriteFile(*out, pemBytes, 0o600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+
+	record, err := keygen.PublishRecord(priv, nil)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s._domainkey.%s. IN TXT %s\n", *selector, *domain, keygen.ChunkRecord(record))
+	return nil
+}
@@ -0,0 +1,96 @@
+package dkim
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var wspRunRegexp = regexp.MustCompile(`[ \t]+`)
+
+// canonicalizeHeader canonicalizes a single raw header field (including its
+// trailing CRLF) according to c.
+func canonicalizeHeader(c string, raw string) (string, error) {
+	switch c {
+	case CanonicalizationSimple:
+		return raw, nil
+	case CanonicalizationRelaxed:
+		i := strings.IndexByte(raw, ':')
+		if i < 0 {
+			return "", fmt.Errorf("dkim: malformed header field: %q", raw)
+		}
+		name := strings.ToLower(strings.TrimSpace(raw[:i]))
+		value := strings.ReplaceAll(raw[i+1:], "\r\n", "")
+		value = wspRunRegexp.ReplaceAllString(value, " ")
+		value = strings.TrimSpace(value)
+		return name + ":" + value + "\r\n", nil
+	default:
+		return "", fmt.Errorf("dkim: unsupported header canonicalization: %q", c)
+	}
+}
+
+// canonicalizeBody canonicalizes a message body according to c.
+func canonicalizeBody(c string, body []byte) ([]byte, error) {
+	switch c {
+	case CanonicalizationSimple:
+		return simpleBodyCanon(body), nil
+	case CanonicalizationRelaxed:
+		return relaxedBodyCanon(body), nil
+	default:
+		return nil, fmt.Errorf("dkim: unsupported body canonicalization: %q", c)
+	}
+}
+
+func splitLines(body []byte) [][]byte {
+	var lines [][]byte
+	for {
+		i := bytes.Index(body, []byte("\r\n"))
+		if i < 0 {
+			lines = append(lines, body)
+			return lines
+		}
+		lines = append(lines, body[:i])
+		body = body[i+2:]
+	}
+}
+
+func trimTrailingEmptyLines(lines [][]byte) [][]byte {
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func joinLines(lines [][]byte) []byte {
+	if len(lines) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.Write(l)
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes()
+}
+
+func simpleBodyCanon(body []byte) []byte {
+	lines := trimTrailingEmptyLines(splitLines(body))
+	if len(lines) == 0 {
+		// RFC 6376 Section 3.4.3: a completely empty or missing body is
+		// canonicalized as a single CRLF.
+		return []byte("\r\n")
+	}
+	return joinLines(lines)
+}
+
+func relaxedBodyCanon(body []byte) []byte {
+	lines := splitLines(body)
+	for i, l := range lines {
+		l = wspRunRegexp.ReplaceAll(l, []byte(" "))
+		l = bytes.TrimRight(l, " ")
+		lines[i] = l
+	}
+	lines = trimTrailingEmptyLines(lines)
+	return joinLines(lines)
+}
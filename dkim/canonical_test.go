@@ -0,0 +1,30 @@
+package dkim
+
+import "testing"
+
+func TestCanonicalizeBody_emptyBody(t *testing.T) {
+	tests := []struct {
+		c    string
+		body string
+		want string
+	}{
+		// RFC 6376 Section 3.4.3: a completely empty or missing body is
+		// canonicalized as a single CRLF under "simple"...
+		{CanonicalizationSimple, "", "\r\n"},
+		{CanonicalizationSimple, "\r\n\r\n\r\n", "\r\n"},
+		// ...but "relaxed" canonicalizes it (and any all-blank body) to the
+		// empty string, since trailing empty lines are trimmed entirely.
+		{CanonicalizationRelaxed, "", ""},
+		{CanonicalizationRelaxed, "\r\n\r\n\r\n", ""},
+	}
+	for _, tt := range tests {
+		got, err := canonicalizeBody(tt.c, []byte(tt.body))
+		if err != nil {
+			t.Errorf("canonicalizeBody(%q, %q): unexpected error: %v", tt.c, tt.body, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("canonicalizeBody(%q, %q) = %q, want %q", tt.c, tt.body, got, tt.want)
+		}
+	}
+}
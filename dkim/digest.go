@@ -0,0 +1,85 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// buildSignedData canonicalizes fields and unsignedHeaderRaw (the complete
+// "Name:value" text of the signature header field itself, with its "b="
+// tag's value left empty) according to c, and concatenates the results in
+// the order required by RFC 6376 to form the bytes that get hashed and
+// signed.
+func buildSignedData(c string, fields []string, unsignedHeaderRaw string) ([]byte, error) {
+	var buf strings.Builder
+	for _, raw := range fields {
+		canon, err := canonicalizeHeader(c, raw)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(canon)
+	}
+	canonSig, err := canonicalizeHeader(c, unsignedHeaderRaw)
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString(strings.TrimSuffix(canonSig, "\r\n"))
+	return []byte(buf.String()), nil
+}
+
+// signDigest hashes data with hash and signs it with signer, returning the
+// algorithm name for the "a=" tag (e.g. "rsa-sha256") and the
+// base64-encoded signature.
+func signDigest(signer crypto.Signer, hash crypto.Hash, data []byte) (algo string, sigB64 string, err error) {
+	algoKey, err := signAlgoName(signer)
+	if err != nil {
+		return "", "", err
+	}
+
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	var sig []byte
+	switch key := signer.(type) {
+	case ed25519.PrivateKey:
+		sig, err = key.Sign(randReader, digest, crypto.Hash(0))
+	default:
+		sig, err = signer.Sign(randReader, digest, hash)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("dkim: failed to sign: %w", err)
+	}
+	return hashAlgoName(algoKey, hash), base64.StdEncoding.EncodeToString(sig), nil
+}
+
+// verifyDigest hashes data with hash and verifies the base64-encoded
+// signature sigB64 against pub.
+func verifyDigest(pub crypto.PublicKey, hash crypto.Hash, data []byte, sigB64 string) error {
+	sig, err := base64.StdEncoding.DecodeString(sigB64)
+	if err != nil {
+		return fmt.Errorf("dkim: malformed b= tag: %w", err)
+	}
+
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	switch key := pub.(type) {
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, digest, sig) {
+			return fmt.Errorf("dkim: signature does not match")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, sig); err != nil {
+			return fmt.Errorf("dkim: signature does not match: %w", err)
+		}
+	default:
+		return fmt.Errorf("dkim: unsupported public key type %T", pub)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Package dkim creates and verifies DKIM signatures, as specified in RFC
+// 6376.
+package dkim
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Supported values for the "c=" tag.
+const (
+	CanonicalizationSimple  = "simple"
+	CanonicalizationRelaxed = "relaxed"
+)
+
+// Supported values for the "a=" tag's signing algorithm component.
+const (
+	algoRSA     = "rsa"
+	algoEd25519 = "ed25519"
+)
+
+// Verification is a DKIM-Signature header field that has been verified.
+type Verification struct {
+	// Domain is the value of the "d=" tag.
+	Domain string
+	// Identifier is the Agent or User Identifier (AUID), the value of the
+	// "i=" tag, if any.
+	Identifier string
+	// HeaderKeys is the list of signed header fields, from the "h=" tag.
+	HeaderKeys []string
+	// Timestamp is the signing time from the "t=" tag, if any.
+	Timestamp time.Time
+	// ExpiresAt is the expiration time from the "x=" tag, if any. A
+	// signature whose expiration has passed is treated as invalid; see Err.
+	ExpiresAt time.Time
+	// AuthenticatedData reports whether the DNS answer for the public key
+	// record was authenticated by DNSSEC, as reported by the Resolver used
+	// to verify this signature.
+	AuthenticatedData bool
+	// Err is nil if the signature is valid.
+	Err error
+}
+
+var errMalformedMessage = errors.New("dkim: malformed MIME message")
+
+// readMessage splits a message into its header fields (each including its
+// trailing CRLF and any folded continuation lines) and its body.
+func readMessage(r io.Reader) (headers []string, body []byte, err error) {
+	br := bufio.NewReader(r)
+
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			headers = append(headers, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for {
+		line, err := br.ReadString('\n')
+		if line == "\r\n" || line == "\n" {
+			flush()
+			body, rerr := io.ReadAll(br)
+			if rerr != nil {
+				return nil, nil, rerr
+			}
+			return headers, body, nil
+		}
+		if len(line) == 0 && err != nil {
+			flush()
+			return headers, nil, nil
+		}
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			cur.WriteString(line)
+		} else {
+			flush()
+			cur.WriteString(line)
+		}
+		if err != nil {
+			flush()
+			return headers, nil, nil
+		}
+	}
+}
+
+// headerName returns the lowercased field name of a raw header field.
+func headerName(raw string) (string, error) {
+	i := strings.IndexByte(raw, ':')
+	if i < 0 {
+		return "", fmt.Errorf("dkim: malformed header field: %q", raw)
+	}
+	return strings.ToLower(strings.TrimSpace(raw[:i])), nil
+}
@@ -0,0 +1,125 @@
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignAndVerify_ed25519 signs with the parameters of RFC 8463 Appendix
+// A.3's test vector (relaxed/relaxed canonicalization, the vector's header
+// list, AUID and timestamp) and checks the resulting "bh=" against the
+// value published in the RFC, to pin down the Ed25519 digest and
+// canonicalization path against a known-good answer instead of only
+// round-tripping through this library's own Sign and Verify.
+func TestSignAndVerify_ed25519(t *testing.T) {
+	const rfc8463BodyHash = "2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv8="
+
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:                 "football.example.com",
+		Selector:               "test",
+		Signer:                 testEd25519PrivateKey,
+		HeaderCanonicalization: CanonicalizationRelaxed,
+		BodyCanonicalization:   CanonicalizationRelaxed,
+		HeaderKeys:             []string{"From", "To", "Subject", "Date", "Message-ID"},
+		AUID:                   "@football.example.com",
+		SignatureTimestamp:     time.Unix(1528637909, 0),
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+
+	signed := strings.ReplaceAll(b.String(), "\r\n ", "")
+	if !strings.Contains(signed, "a=ed25519-sha256;") {
+		t.Errorf("Expected signature to use a=ed25519-sha256, got:\n%v", b.String())
+	}
+	if !strings.Contains(signed, "bh="+rfc8463BodyHash+";") {
+		t.Errorf("Expected bh=%s, matching RFC 8463 Appendix A.3, got:\n%v", rfc8463BodyHash, b.String())
+	}
+
+	verifications, err := Verify(&b)
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 {
+		t.Fatal("Expected exactly one verification")
+	}
+	v := verifications[0]
+	if err := v.Err; err != nil {
+		t.Errorf("Expected no error when verifying ed25519 signature, got: %v", err)
+	}
+	if v.Domain != options.Domain {
+		t.Errorf("Expected domain to be %q but got %q", options.Domain, v.Domain)
+	}
+}
+
+func TestSignAndVerify_ed25519_ignoresHashOption(t *testing.T) {
+	// RFC 8463: Ed25519 signatures always use SHA-256. A Hash set for an
+	// RSA selector sharing the same options helper must not leak into an
+	// Ed25519 selector's digest, or the resulting a=ed25519-sha256 label
+	// would be lying about how bh=/b= were actually computed.
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:   "football.example.com",
+		Selector: "test",
+		Signer:   testEd25519PrivateKey,
+		Hash:     crypto.SHA1,
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+
+	verifications, err := Verify(&b)
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 || verifications[0].Err != nil {
+		t.Errorf("Expected a valid verification despite Hash: crypto.SHA1, got: %+v", verifications)
+	}
+}
+
+func TestSignAndVerify_dualSign(t *testing.T) {
+	// Dual-signing with RSA and Ed25519 selectors lets operators migrate
+	// verifiers gradually, per RFC 8463 Section 3.
+	r := strings.NewReader(mailString)
+
+	var rsaSig bytes.Buffer
+	if err := Sign(&rsaSig, r, &SignOptions{
+		Domain:   "example.org",
+		Selector: "brisbane",
+		Signer:   testPrivateKey,
+	}); err != nil {
+		t.Fatal("Expected no error while signing with RSA, got:", err)
+	}
+
+	// Add the Ed25519 signature on top of the RSA-signed message, so that the
+	// result carries both DKIM-Signature header fields.
+	var dualSig bytes.Buffer
+	if err := Sign(&dualSig, bytes.NewReader(rsaSig.Bytes()), &SignOptions{
+		Domain:   "football.example.com",
+		Selector: "test",
+		Signer:   testEd25519PrivateKey,
+	}); err != nil {
+		t.Fatal("Expected no error while signing with Ed25519, got:", err)
+	}
+
+	verifications, err := Verify(bytes.NewReader(dualSig.Bytes()))
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signatures, got: %v", err)
+	}
+	if len(verifications) != 2 {
+		t.Fatalf("Expected two verifications, got %d", len(verifications))
+	}
+	for _, v := range verifications {
+		if v.Err != nil {
+			t.Errorf("Expected a valid verification for d=%s, got: %v", v.Domain, v.Err)
+		}
+	}
+}
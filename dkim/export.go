@@ -0,0 +1,121 @@
+package dkim
+
+import (
+	"context"
+	"crypto"
+	"io"
+)
+
+// The functions in this file expose DKIM's message parsing, canonicalization
+// and signing primitives so that related specifications built on top of
+// DKIM, such as ARC (RFC 8617), can reuse them instead of reimplementing
+// RFC 6376 from scratch.
+
+// ReadMessage splits a message read from r into its header fields (each
+// including any folded continuation lines) and its body.
+func ReadMessage(r io.Reader) (headers []string, body []byte, err error) {
+	return readMessage(r)
+}
+
+// CanonicalizeHeader canonicalizes a single raw header field (including its
+// trailing CRLF) according to c, either CanonicalizationSimple or
+// CanonicalizationRelaxed.
+func CanonicalizeHeader(c string, raw string) (string, error) {
+	return canonicalizeHeader(c, raw)
+}
+
+// CanonicalizeBody canonicalizes a message body according to c, either
+// CanonicalizationSimple or CanonicalizationRelaxed.
+func CanonicalizeBody(c string, body []byte) ([]byte, error) {
+	return canonicalizeBody(c, body)
+}
+
+// PickHeaders returns, for each name in keys (bottom-up, as per RFC 6376
+// Section 5.4.2), the raw header field from headers.
+func PickHeaders(headers []string, keys []string) ([]string, error) {
+	return pickHeaders(headers, keys)
+}
+
+// FoldHeader returns a complete "Name:value\r\n" header field, folding value
+// onto continuation lines the same way Sign does.
+func FoldHeader(name string, value string) string {
+	return foldHeader(name, value)
+}
+
+// BuildSignedData canonicalizes fields and unsignedHeaderRaw (the complete
+// "Name:value" text of a signature header field, with its "b=" tag's value
+// left empty) according to c, and concatenates the results in the order
+// required by RFC 6376 Section 3.7 to form the bytes that get hashed and
+// signed.
+func BuildSignedData(c string, fields []string, unsignedHeaderRaw string) ([]byte, error) {
+	return buildSignedData(c, fields, unsignedHeaderRaw)
+}
+
+// SignDigest hashes data with hash and signs it with signer, returning the
+// algorithm name for the "a=" tag (e.g. "rsa-sha256") and the
+// base64-encoded signature.
+func SignDigest(signer crypto.Signer, hash crypto.Hash, data []byte) (algo string, sigB64 string, err error) {
+	return signDigest(signer, hash, data)
+}
+
+// VerifyDigest hashes data with hash and verifies the base64-encoded
+// signature sigB64 against pub.
+func VerifyDigest(pub crypto.PublicKey, hash crypto.Hash, data []byte, sigB64 string) error {
+	return verifyDigest(pub, hash, data, sigB64)
+}
+
+// LookupPublicKey fetches and parses the public key published at
+// selector._domainkey.domain, using DefaultResolver.
+func LookupPublicKey(domain, selector string) (crypto.PublicKey, string, error) {
+	pub, algo, _, err := lookupPublicKey(context.Background(), DefaultResolver, domain, selector)
+	return pub, algo, err
+}
+
+// LookupPublicKeyWithResolver is like LookupPublicKey, but resolves the key
+// record using resolver instead of DefaultResolver, reporting whether the
+// DNS answer was authenticated by DNSSEC.
+func LookupPublicKeyWithResolver(ctx context.Context, resolver Resolver, domain, selector string) (crypto.PublicKey, string, bool, error) {
+	return lookupPublicKey(ctx, resolver, domain, selector)
+}
+
+// ParseTagList parses a raw header field into its semicolon-separated
+// tag=value pairs, unfolding it first.
+func ParseTagList(raw string) (map[string]string, error) {
+	return parseTagList(raw)
+}
+
+// SplitAlgo splits an "a=" tag value into its key algorithm and hash
+// algorithm components (e.g. "rsa-sha256" into "rsa" and "sha256").
+func SplitAlgo(a string) (algo, hashAlgo string, err error) {
+	return splitAlgo(a)
+}
+
+// SplitCanon splits a "c=" tag value into its header and body
+// canonicalization components.
+func SplitCanon(c string) (header, body string, err error) {
+	return splitCanon(c)
+}
+
+// ParseHashAlgo returns the crypto.Hash for a hash algorithm name such as
+// "sha256".
+func ParseHashAlgo(name string) (crypto.Hash, error) {
+	return parseHashAlgo(name)
+}
+
+// UnsignHeader returns the unfolded "Name:value" text of a signature header
+// field with its "b=" tag's value cleared, matching what the signer hashed.
+func UnsignHeader(raw string, b string) (string, error) {
+	return unsignHeader(raw, b)
+}
+
+// SignAlgoName returns the algorithm name for the "a=" tag's key component
+// (e.g. "rsa" or "ed25519") based on signer's concrete type.
+func SignAlgoName(signer crypto.Signer) (string, error) {
+	return signAlgoName(signer)
+}
+
+// HashAlgoName returns the full algorithm name for the "a=" tag (e.g.
+// "rsa-sha256") given a key algorithm and a hash.
+func HashAlgoName(algo string, hash crypto.Hash) string {
+	return hashAlgoName(algo, hash)
+}
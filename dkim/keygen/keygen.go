@@ -0,0 +1,154 @@
+// Package keygen generates DKIM key pairs and the DNS TXT record contents
+// needed to publish them, for use by operators rotating or provisioning
+// DKIM selectors. It's a companion to the dkim package, which only
+// consumes keys that are already published.
+package keygen
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jensbjorgensen/go-msgauth/dkim"
+)
+
+// randReader is used to generate key material. It's a variable so that
+// tests can substitute a deterministic source.
+var randReader = cryptorand.Reader
+
+// recordChunkSize is the maximum length of a single quoted string within a
+// generated TXT record, matching the 255-byte limit RFC 1035 places on a
+// DNS character-string.
+const recordChunkSize = 255
+
+// GenerateRSA generates an RSA key pair of the given size in bits, which
+// must be 2048 or 4096.
+func GenerateRSA(bits int) (*rsa.PrivateKey, error) {
+	switch bits {
+	case 2048, 4096:
+	default:
+		return nil, fmt.Errorf("dkim/keygen: unsupported RSA key size: %d", bits)
+	}
+	return rsa.GenerateKey(randReader, bits)
+}
+
+// GenerateEd25519 generates an Ed25519 key pair.
+func GenerateEd25519() (ed25519.PrivateKey, error) {
+	_, priv, err := ed25519.GenerateKey(randReader)
+	return priv, err
+}
+
+// MarshalPrivateKeyPEM encodes priv, which must be an *rsa.PrivateKey or an
+// ed25519.PrivateKey, as a PKCS#8 "PRIVATE KEY" PEM block.
+func MarshalPrivateKeyPEM(priv crypto.Signer) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("dkim/keygen: failed to marshal private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// RecordOptions controls the DNS TXT record content produced by
+// PublishRecord.
+type RecordOptions struct {
+	// Notes, if non-empty, is included as the record's "n=" tag, a
+	// human-readable comment.
+	Notes string
+	// ServiceType, if non-empty, is included as the record's "s=" tag
+	// restricting which services may use the key (e.g. "email").
+	ServiceType string
+}
+
+// PublishRecord returns the DNS TXT record content publishing priv's public
+// key, in the "v=DKIM1; k=...; p=..." format described by RFC 6376 Section
+// 3.6.1. priv must be an *rsa.PrivateKey or an ed25519.PrivateKey.
+//
+// The returned string is the record's logical value; it is not split into
+// 255-byte chunks. Use ChunkRecord to produce zone-file-ready text.
+func PublishRecord(priv crypto.Signer, opts *RecordOptions) (string, error) {
+	var algo, p string
+	switch pub := priv.Public().(type) {
+	case *rsa.PublicKey:
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		if err != nil {
+			return "", fmt.Errorf("dkim/keygen: failed to marshal public key: %w", err)
+		}
+		algo = "rsa"
+		p = base64.StdEncoding.EncodeToString(der)
+	case ed25519.PublicKey:
+		algo = "ed25519"
+		p = base64.StdEncoding.EncodeToString(pub)
+	default:
+		return "", fmt.Errorf("dkim/keygen: unsupported key type: %T", priv.Public())
+	}
+
+	tags := []string{"v=DKIM1"}
+	if opts != nil && opts.ServiceType != "" {
+		tags = append(tags, "s="+opts.ServiceType)
+	}
+	if opts != nil && opts.Notes != "" {
+		tags = append(tags, "n="+opts.Notes)
+	}
+	tags = append(tags, "k="+algo, "p="+p)
+	return strings.Join(tags, "; "), nil
+}
+
+// ChunkRecord splits record into double-quoted strings of at most 255
+// bytes each, separated by spaces, as required for a multi-string TXT
+// record in a zone file (e.g. to accommodate the long "p=" tag of an RSA
+// key).
+func ChunkRecord(record string) string {
+	var chunks []string
+	for len(record) > 0 {
+		n := recordChunkSize
+		if n > len(record) {
+			n = len(record)
+		}
+		chunks = append(chunks, strconv.Quote(record[:n]))
+		record = record[n:]
+	}
+	return strings.Join(chunks, " ")
+}
+
+// VerifyKeyMatchesRecord fetches the TXT record for selector._domainkey.domain
+// using resolver and confirms that it publishes the same public key as
+// priv, mirroring the check performed by tools such as chasquid-util's
+// "dkim" subcommand before a rotation is considered complete.
+func VerifyKeyMatchesRecord(ctx context.Context, resolver dkim.Resolver, domain, selector string, priv crypto.Signer) error {
+	pub, algo, _, err := dkim.LookupPublicKeyWithResolver(ctx, resolver, domain, selector)
+	if err != nil {
+		return fmt.Errorf("dkim/keygen: failed to look up published key: %w", err)
+	}
+
+	wantAlgo, err := dkim.SignAlgoName(priv)
+	if err != nil {
+		return err
+	}
+	if algo != wantAlgo {
+		return fmt.Errorf("dkim/keygen: published key algorithm %q does not match %q", algo, wantAlgo)
+	}
+
+	switch want := priv.Public().(type) {
+	case *rsa.PublicKey:
+		got, ok := pub.(*rsa.PublicKey)
+		if !ok || !got.Equal(want) {
+			return fmt.Errorf("dkim/keygen: published key does not match private key")
+		}
+	case ed25519.PublicKey:
+		got, ok := pub.(ed25519.PublicKey)
+		if !ok || !got.Equal(want) {
+			return fmt.Errorf("dkim/keygen: published key does not match private key")
+		}
+	default:
+		return fmt.Errorf("dkim/keygen: unsupported key type: %T", want)
+	}
+	return nil
+}
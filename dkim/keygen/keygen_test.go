@@ -0,0 +1,123 @@
+package keygen
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/jensbjorgensen/go-msgauth/dkim"
+)
+
+func TestGenerateRSA_unsupportedSize(t *testing.T) {
+	if _, err := GenerateRSA(1024); err == nil {
+		t.Fatal("Expected an error for an unsupported RSA key size, got none")
+	}
+}
+
+func TestMarshalPrivateKeyPEM_ed25519(t *testing.T) {
+	priv, err := GenerateEd25519()
+	if err != nil {
+		t.Fatalf("Expected no error generating key, got: %v", err)
+	}
+
+	pemBytes, err := MarshalPrivateKeyPEM(priv)
+	if err != nil {
+		t.Fatalf("Expected no error marshaling key, got: %v", err)
+	}
+
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		t.Fatalf("Expected a PRIVATE KEY PEM block, got: %v", block)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("Expected no error parsing PKCS#8 key, got: %v", err)
+	}
+	if _, ok := parsed.(ed25519.PrivateKey); !ok {
+		t.Fatalf("Expected an ed25519.PrivateKey, got %T", parsed)
+	}
+}
+
+func TestPublishRecord_ed25519(t *testing.T) {
+	priv, err := GenerateEd25519()
+	if err != nil {
+		t.Fatalf("Expected no error generating key, got: %v", err)
+	}
+
+	record, err := PublishRecord(priv, nil)
+	if err != nil {
+		t.Fatalf("Expected no error publishing record, got: %v", err)
+	}
+	if !strings.HasPrefix(record, "v=DKIM1; ") {
+		t.Errorf("Expected record to start with v=DKIM1, got: %q", record)
+	}
+	if !strings.Contains(record, "k=ed25519;") {
+		t.Errorf("Expected record to contain k=ed25519, got: %q", record)
+	}
+}
+
+func TestPublishRecord_rsaWithOptions(t *testing.T) {
+	priv, err := GenerateRSA(2048)
+	if err != nil {
+		t.Fatalf("Expected no error generating key, got: %v", err)
+	}
+
+	record, err := PublishRecord(priv, &RecordOptions{ServiceType: "email", Notes: "rotated 2026-07"})
+	if err != nil {
+		t.Fatalf("Expected no error publishing record, got: %v", err)
+	}
+	if !strings.Contains(record, "k=rsa;") {
+		t.Errorf("Expected record to contain k=rsa, got: %q", record)
+	}
+	if !strings.Contains(record, "s=email;") {
+		t.Errorf("Expected record to contain s=email, got: %q", record)
+	}
+	if !strings.Contains(record, "n=rotated 2026-07;") {
+		t.Errorf("Expected record to contain the n= tag, got: %q", record)
+	}
+
+	chunked := ChunkRecord(record)
+	inner := strings.TrimSuffix(strings.TrimPrefix(chunked, `"`), `"`)
+	var rebuilt strings.Builder
+	for _, chunk := range strings.Split(inner, `" "`) {
+		if len(chunk) > 255 {
+			t.Errorf("Expected each chunk to be at most 255 bytes, got %d", len(chunk))
+		}
+		rebuilt.WriteString(chunk)
+	}
+	if rebuilt.String() != record {
+		t.Errorf("Expected chunks to reassemble into the original record, got %q", rebuilt.String())
+	}
+}
+
+func TestVerifyKeyMatchesRecord(t *testing.T) {
+	priv, err := GenerateEd25519()
+	if err != nil {
+		t.Fatalf("Expected no error generating key, got: %v", err)
+	}
+	record, err := PublishRecord(priv, nil)
+	if err != nil {
+		t.Fatalf("Expected no error publishing record, got: %v", err)
+	}
+
+	resolver := dkim.MapResolver{
+		Records: map[string][]string{
+			"selector._domainkey.example.org": {record},
+		},
+	}
+
+	if err := VerifyKeyMatchesRecord(context.Background(), resolver, "example.org", "selector", priv); err != nil {
+		t.Errorf("Expected the published key to match, got: %v", err)
+	}
+
+	other, err := GenerateEd25519()
+	if err != nil {
+		t.Fatalf("Expected no error generating key, got: %v", err)
+	}
+	if err := VerifyKeyMatchesRecord(context.Background(), resolver, "example.org", "selector", other); err == nil {
+		t.Error("Expected a mismatched key to fail verification, got none")
+	}
+}
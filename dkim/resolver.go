@@ -0,0 +1,46 @@
+package dkim
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// Resolver resolves DKIM public key records over DNS. The ad return value
+// reports whether the answer was authenticated by DNSSEC (i.e. the
+// resolver set the AD bit), so that callers can implement DNSSEC-gated
+// policies such as DMARC or DANE alignment.
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) (txts []string, ad bool, err error)
+}
+
+// DefaultResolver is the Resolver used by Verify and by VerifyWithOptions
+// when VerifyOptions.Resolver is nil. It wraps net.DefaultResolver and
+// never reports DNSSEC authentication, since the standard library does not
+// expose the AD bit.
+var DefaultResolver Resolver = netResolver{}
+
+type netResolver struct{}
+
+func (netResolver) LookupTXT(ctx context.Context, name string) ([]string, bool, error) {
+	txts, err := net.DefaultResolver.LookupTXT(ctx, name)
+	return txts, false, err
+}
+
+// MapResolver is a Resolver backed by a static map of TXT records, keyed by
+// fully-qualified name (e.g. "selector._domainkey.example.org"). It's
+// useful for tests that want to stub DNS deterministically instead of
+// hitting real nameservers.
+type MapResolver struct {
+	Records map[string][]string
+	// AD marks every record served by this resolver as DNSSEC-authenticated.
+	AD bool
+}
+
+func (m MapResolver) LookupTXT(ctx context.Context, name string) ([]string, bool, error) {
+	txts, ok := m.Records[name]
+	if !ok {
+		return nil, false, fmt.Errorf("dkim: no such host: %s", name)
+	}
+	return txts, m.AD, nil
+}
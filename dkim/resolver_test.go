@@ -0,0 +1,78 @@
+package dkim
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestVerifyWithOptions_customResolver(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:   "example.org",
+		Selector: "brisbane",
+		Signer:   testPrivateKey,
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+
+	resolver, ok := DefaultResolver.(MapResolver)
+	if !ok {
+		t.Fatalf("Expected DefaultResolver to be a MapResolver in tests, got %T", DefaultResolver)
+	}
+	resolver.AD = true
+
+	verifications, err := VerifyWithOptions(context.Background(), &b, &VerifyOptions{Resolver: resolver})
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 {
+		t.Fatal("Expected exactly one verification")
+	}
+	v := verifications[0]
+	if err := v.Err; err != nil {
+		t.Errorf("Expected no error when verifying signature, got: %v", err)
+	}
+	if !v.AuthenticatedData {
+		t.Error("Expected AuthenticatedData to be true when the resolver reports a DNSSEC-authenticated answer")
+	}
+}
+
+func TestVerify_unauthenticatedData(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:   "example.org",
+		Selector: "brisbane",
+		Signer:   testPrivateKey,
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+
+	resolver, ok := DefaultResolver.(MapResolver)
+	if !ok {
+		t.Fatalf("Expected DefaultResolver to be a MapResolver in tests, got %T", DefaultResolver)
+	}
+	resolver.AD = false
+
+	verifications, err := VerifyWithOptions(context.Background(), &b, &VerifyOptions{Resolver: resolver})
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 {
+		t.Fatal("Expected exactly one verification")
+	}
+	v := verifications[0]
+	if err := v.Err; err != nil {
+		t.Errorf("Expected no error when verifying signature, got: %v", err)
+	}
+	if v.AuthenticatedData {
+		t.Error("Expected AuthenticatedData to be false when the resolver does not report a DNSSEC-authenticated answer")
+	}
+}
@@ -0,0 +1,257 @@
+package dkim
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// randReader is used to generate randomness during signing. It's a variable
+// so that tests can substitute a deterministic source.
+var randReader io.Reader = cryptorand.Reader
+
+// now returns the current time. It's a variable so that tests can pin the
+// value used for the "t=" tag.
+var now = time.Now
+
+const foldWidth = 75
+
+// SignOptions is used to configure Sign.
+type SignOptions struct {
+	// Domain is the domain signing the message, used to populate the "d="
+	// tag. Required.
+	Domain string
+	// Selector is the DKIM selector, used to populate the "s=" tag. Required.
+	Selector string
+	// Signer is the private key used to sign the message. It must be either
+	// an *rsa.PrivateKey or an ed25519.PrivateKey. Required.
+	Signer crypto.Signer
+
+	// Hash is the hash function used to compute the body and header hashes.
+	// Defaults to crypto.SHA256. Only used when Signer is an RSA key: Ed25519
+	// signatures always use SHA-256, per RFC 8463.
+	Hash crypto.Hash
+
+	// HeaderCanonicalization is the canonicalization algorithm for the
+	// header, either "simple" or "relaxed". Defaults to "simple".
+	HeaderCanonicalization string
+	// BodyCanonicalization is the canonicalization algorithm for the body,
+	// either "simple" or "relaxed". Defaults to "simple".
+	BodyCanonicalization string
+
+	// HeaderKeys is the list of header fields to sign, used to populate the
+	// "h=" tag. If empty, all header fields present in the message are
+	// signed, in the order they appear.
+	HeaderKeys []string
+	// OversignHeaderKeys is a list of header fields to additionally list a
+	// second time in the "h=" tag, without a corresponding signed
+	// occurrence. This is a hardening measure: it makes the signature
+	// invalid if a header field with that name is later added to the
+	// message, which would otherwise be ignored by a verifier that only
+	// checks the bottom-most occurrence of each signed header.
+	OversignHeaderKeys []string
+
+	// BodyLimit, if positive, limits the number of canonicalized body octets
+	// that are hashed and populates the "l=" tag with that count. This lets
+	// a verifier detect (but not reject outright) content appended to the
+	// body after signing. Leave zero to hash and cover the entire body.
+	BodyLimit int64
+
+	// SignatureTimestamp is the signing time used to populate the "t=" tag.
+	// The zero value means now, as reported by the time package's clock;
+	// a negative Unix time (e.g. time.Unix(-1, 0)) omits the "t=" tag
+	// entirely.
+	SignatureTimestamp time.Time
+	// SignatureExpiresIn, if positive, populates the "x=" tag with
+	// SignatureTimestamp plus this duration, or now plus this duration if
+	// SignatureTimestamp is zero or omits the "t=" tag.
+	SignatureExpiresIn time.Duration
+	// AUID is the Agent or User Identifier, used to populate the "i=" tag.
+	// It must be empty, or a string of the form "local-part@domain" where
+	// domain is equal to, or a subdomain of, Domain.
+	AUID string
+}
+
+func (options *SignOptions) hash() crypto.Hash {
+	if _, ok := options.Signer.(ed25519.PrivateKey); ok {
+		// RFC 8463: Ed25519 signatures always use SHA-256, regardless of
+		// Hash.
+		return crypto.SHA256
+	}
+	if options.Hash != 0 {
+		return options.Hash
+	}
+	return crypto.SHA256
+}
+
+func (options *SignOptions) headerCanonicalization() string {
+	if options.HeaderCanonicalization != "" {
+		return options.HeaderCanonicalization
+	}
+	return CanonicalizationSimple
+}
+
+func (options *SignOptions) bodyCanonicalization() string {
+	if options.BodyCanonicalization != "" {
+		return options.BodyCanonicalization
+	}
+	return CanonicalizationSimple
+}
+
+// signatureTime returns the time to use for the "t=" tag, and whether the
+// tag should be omitted entirely.
+func (options *SignOptions) signatureTime() (t time.Time, omit bool) {
+	switch {
+	case options.SignatureTimestamp.IsZero():
+		return now(), false
+	case options.SignatureTimestamp.Unix() < 0:
+		return time.Time{}, true
+	default:
+		return options.SignatureTimestamp, false
+	}
+}
+
+// validateAUID checks that auid is a "local-part@domain" identifier whose
+// domain is equal to, or a subdomain of, domain, as required by RFC 6376
+// Section 3.5 for the "i=" tag.
+func validateAUID(auid, domain string) error {
+	i := strings.LastIndexByte(auid, '@')
+	if i < 0 {
+		return fmt.Errorf("dkim: invalid AUID: %q", auid)
+	}
+	auidDomain := auid[i+1:]
+	if !strings.EqualFold(auidDomain, domain) && !strings.HasSuffix(strings.ToLower(auidDomain), "."+strings.ToLower(domain)) {
+		return fmt.Errorf("dkim: AUID domain %q is not within d=%q", auidDomain, domain)
+	}
+	return nil
+}
+
+// Sign signs a message read from r, writing the signed message to w. It adds
+// a DKIM-Signature header field ahead of the message's own header fields.
+//
+// Sign streams the message through a Writer rather than holding the whole
+// body in memory; see Writer and Signer for the lower-level primitives, for
+// example to sign a message whose body is too large to buffer.
+func Sign(w io.Writer, r io.Reader, options *SignOptions) error {
+	sw, err := NewWriter(w, options)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(sw, r); err != nil {
+		sw.Close()
+		return err
+	}
+	return sw.Close()
+}
+
+func computeSignature(options *SignOptions, fields []string, unsignedValue string) (string, error) {
+	data, err := buildSignedData(options.headerCanonicalization(), fields, "DKIM-Signature:"+unsignedValue)
+	if err != nil {
+		return "", err
+	}
+	_, sig, err := signDigest(options.Signer, options.hash(), data)
+	return sig, err
+}
+
+func signAlgoName(signer crypto.Signer) (string, error) {
+	switch signer.(type) {
+	case ed25519.PrivateKey:
+		return algoEd25519, nil
+	case *rsa.PrivateKey:
+		return algoRSA, nil
+	default:
+		return "", fmt.Errorf("dkim: unsupported signer type %T", signer)
+	}
+}
+
+func hashAlgoName(algo string, hash crypto.Hash) string {
+	if algo == algoEd25519 {
+		return algoEd25519 + "-sha256"
+	}
+	switch hash {
+	case crypto.SHA1:
+		return algo + "-sha1"
+	default:
+		return algo + "-sha256"
+	}
+}
+
+func requireFromHeader(keys []string) error {
+	for _, k := range keys {
+		if strings.EqualFold(k, "from") {
+			return nil
+		}
+	}
+	return errors.New("dkim: message must have a From header field")
+}
+
+// pickHeaders returns, for each name in keys (bottom-up, as per RFC 6376),
+// the raw header field to include in the signature. A name with no
+// remaining available occurrence (e.g. an oversigned header field, or more
+// occurrences of a name in keys than are present in headers) contributes no
+// field, per RFC 6376 Section 3.5.
+func pickHeaders(headers []string, keys []string) ([]string, error) {
+	byName := make(map[string][]string)
+	for _, raw := range headers {
+		name, err := headerName(raw)
+		if err != nil {
+			return nil, err
+		}
+		byName[name] = append(byName[name], raw)
+	}
+
+	var fields []string
+	for _, k := range keys {
+		name := strings.ToLower(k)
+		avail := byName[name]
+		if len(avail) == 0 {
+			continue
+		}
+		fields = append(fields, avail[len(avail)-1])
+		byName[name] = avail[:len(avail)-1]
+	}
+	return fields, nil
+}
+
+// foldHeader returns a complete "Name:value\r\n" header field, folding value
+// onto continuation lines (each prefixed with a single space). The first
+// line, name and colon included, holds at most foldWidth characters; each
+// continuation line holds at most foldWidth characters after its leading
+// space. A name of foldWidth-1 characters or more leaves value unfolded.
+func foldHeader(name string, value string) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	sb.WriteByte(':')
+
+	rest := value
+	first := true
+	for len(rest) > 0 {
+		width := foldWidth
+		if first {
+			width = foldWidth - len(name) - 1
+		}
+		if width <= 0 || len(rest) <= width {
+			if !first {
+				sb.WriteString("\r\n ")
+			}
+			sb.WriteString(rest)
+			break
+		}
+		if !first {
+			sb.WriteString("\r\n ")
+		}
+		sb.WriteString(rest[:width])
+		rest = rest[width:]
+		first = false
+	}
+	sb.WriteString("\r\n")
+	return sb.String()
+}
@@ -3,9 +3,10 @@ package dkim
 import (
 	"bytes"
 	"crypto"
-	"math/rand"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 )
 
 const mailHeaderString = "From: Joe SixPack <joe@football.example.com>\r\n" +
@@ -24,15 +25,17 @@ const mailString = mailHeaderString + "\r\n" + mailBodyString
 
 const signedMailString = "DKIM-Signature: a=rsa-sha256; bh=2jUSOH9NhtVGCQWNr9BrIAPreKQjO6Sn7XIkfJVOzv" + "\r\n" +
 	" " + "8=; c=simple/simple; d=example.org; h=From:To:Subject:Date:Message-ID; s=br" + "\r\n" +
-	" " + "isbane; t=424242; v=1; b=bXtqB8uOEvtd1Xv/DHatdjb9onP0+vnzdYBbPMZm1qrRmhSuFH" + "\r\n" +
-	" " + "WsbkETafswNvJ4VqNX0gMoaYvzcmoMkUhW9m4pgZqR5y+62yA+B7WJCd6mz82UVkS1qEJeGjMxX" + "\r\n" +
-	" " + "mmPDkmLDA5HHL5LLTc3DLrxkwWMLzwrhQL48WhNFD1d6L4=;" + "\r\n" +
+	" " + "isbane; t=424242; v=1; b=DL3woyh151Eyrz7yzqYk24rxDk4beslRVWrskX1cIEa4igzARD" + "\r\n" +
+	" " + "lp7UJUq6DLaXl99x8ar4pP0nK4z0H1WMqqeq0lieqq91wr9WxYb9XaIPEuGnpfvr2R+YwRb8hjp" + "\r\n" +
+	" " + "VwJRJ17rx3o1n0Te18aPcCqSCu2iJ0o0QiNdXaMS9afCjY=;" + "\r\n" +
 	mailHeaderString +
 	"\r\n" +
 	mailBodyString
 
 func init() {
-	randReader = rand.New(rand.NewSource(42))
+	now = func() time.Time {
+		return time.Unix(424242, 0)
+	}
 }
 
 func TestSign(t *testing.T) {
@@ -107,6 +110,169 @@ func TestSignAndVerify_relaxed(t *testing.T) {
 	}
 }
 
+func TestSignAndVerify_timestampAndExpiry(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:             "example.org",
+		Selector:           "brisbane",
+		Signer:             testPrivateKey,
+		SignatureTimestamp: time.Unix(423242, 0),
+		SignatureExpiresIn: 2000 * time.Second,
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+	if !strings.Contains(b.String(), "t=423242;") {
+		t.Errorf("Expected a t=423242 tag, got:\n%v", b.String())
+	}
+	if !strings.Contains(b.String(), "x=425242;") {
+		t.Errorf("Expected an x=425242 tag, got:\n%v", b.String())
+	}
+
+	verifications, err := Verify(&b)
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 {
+		t.Fatal("Expected exactly one verification")
+	}
+	v := verifications[0]
+	if err := v.Err; err != nil {
+		t.Errorf("Expected no error verifying an unexpired signature, got: %v", err)
+	}
+	if !v.Timestamp.Equal(time.Unix(423242, 0)) {
+		t.Errorf("Expected Timestamp to be %v, got %v", time.Unix(423242, 0), v.Timestamp)
+	}
+	if !v.ExpiresAt.Equal(time.Unix(425242, 0)) {
+		t.Errorf("Expected ExpiresAt to be %v, got %v", time.Unix(425242, 0), v.ExpiresAt)
+	}
+}
+
+func TestSignAndVerify_expired(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:             "example.org",
+		Selector:           "brisbane",
+		Signer:             testPrivateKey,
+		SignatureTimestamp: time.Unix(1000, 0),
+		SignatureExpiresIn: time.Second,
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+
+	verifications, err := Verify(&b)
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 || verifications[0].Err == nil {
+		t.Error("Expected an expired signature to fail verification")
+	}
+}
+
+func TestSign_omitTimestamp(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:             "example.org",
+		Selector:           "brisbane",
+		Signer:             testPrivateKey,
+		SignatureTimestamp: time.Unix(-1, 0),
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+	if strings.Contains(b.String(), "t=") {
+		t.Errorf("Expected no t= tag, got:\n%v", b.String())
+	}
+}
+
+func TestSignAndVerify_omitTimestampWithExpiry(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:             "example.org",
+		Selector:           "brisbane",
+		Signer:             testPrivateKey,
+		SignatureTimestamp: time.Unix(-1, 0),
+		SignatureExpiresIn: 2000 * time.Second,
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+
+	// now() is pinned to time.Unix(424242, 0) by this file's init; with t=
+	// omitted, x= must still be computed relative to now, not to the zero
+	// time.Time{} that signatureTime() returns for the omitted "t=".
+	wantExpiry := fmt.Sprintf("x=%d", time.Unix(424242, 0).Add(options.SignatureExpiresIn).Unix())
+	if !strings.Contains(b.String(), wantExpiry) {
+		t.Errorf("Expected %s, got:\n%v", wantExpiry, b.String())
+	}
+
+	verifications, err := Verify(&b)
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 || verifications[0].Err != nil {
+		t.Errorf("Expected a valid, unexpired verification, got: %+v", verifications)
+	}
+}
+
+func TestSignAndVerify_auid(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:   "example.org",
+		Selector: "brisbane",
+		Signer:   testPrivateKey,
+		AUID:     "joe@mail.example.org",
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+	unfolded := strings.ReplaceAll(b.String(), "\r\n ", "")
+	if !strings.Contains(unfolded, "i=joe@mail.example.org;") {
+		t.Errorf("Expected an i= tag, got:\n%v", b.String())
+	}
+
+	verifications, err := Verify(&b)
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 || verifications[0].Err != nil {
+		t.Fatalf("Expected a valid verification, got: %+v", verifications)
+	}
+	if verifications[0].Identifier != options.AUID {
+		t.Errorf("Expected Identifier to be %q, got %q", options.AUID, verifications[0].Identifier)
+	}
+}
+
+func TestSign_invalidAUID(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:   "example.org",
+		Selector: "brisbane",
+		Signer:   testPrivateKey,
+		AUID:     "joe@evil.example.com",
+	}
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err == nil {
+		t.Error("Expected an error when AUID's domain is not within d=")
+	}
+
+	options.AUID = "joe"
+	if err := Sign(&b, r, options); err == nil {
+		t.Error("Expected an error when AUID has no domain part")
+	}
+}
+
 func TestSign_invalidOptions(t *testing.T) {
 	r := strings.NewReader(mailString)
 	var b bytes.Buffer
@@ -143,12 +309,6 @@ func TestSign_invalidOptions(t *testing.T) {
 	}
 	options.BodyCanonicalization = ""
 
-	options.BodyCanonicalization = "potatoe"
-	if err := Sign(&b, r, options); err == nil {
-		t.Error("Expected an error when signing a message with an invalid body canonicalization")
-	}
-	options.BodyCanonicalization = ""
-
 	options.Hash = ^crypto.Hash(0)
 	if err := Sign(&b, r, options); err == nil {
 		t.Error("Expected an error when signing a message with an invalid hash algorithm")
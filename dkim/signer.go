@@ -0,0 +1,402 @@
+package dkim
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// Signer incrementally computes a DKIM-Signature header field for a
+// message, using memory proportional to the size of the message's header
+// fields rather than its whole body, regardless of how large the body is.
+//
+// Write the message (its header fields, a blank line, and its body, exactly
+// as Sign would receive it) to a Signer in any number of calls, then call
+// Close and Signature. A Signer only computes the signature; it does not
+// emit the signed message itself. Use Writer to also produce signed output.
+type Signer struct {
+	options  *SignOptions
+	algoName string
+
+	headerBuf   bytes.Buffer
+	headersDone bool
+	headerKeys  []string
+	fields      []string
+
+	canon *streamingBodyCanon
+
+	sig    string
+	err    error
+	closed bool
+}
+
+// NewSigner creates a Signer that will compute a DKIM-Signature header field
+// according to options.
+func NewSigner(options *SignOptions) (*Signer, error) {
+	if options == nil {
+		return nil, errors.New("dkim: missing options")
+	}
+	if options.Domain == "" {
+		return nil, errors.New("dkim: missing domain")
+	}
+	if options.Selector == "" {
+		return nil, errors.New("dkim: missing selector")
+	}
+	if options.Signer == nil {
+		return nil, errors.New("dkim: missing signer")
+	}
+	switch options.headerCanonicalization() {
+	case CanonicalizationSimple, CanonicalizationRelaxed:
+	default:
+		return nil, fmt.Errorf("dkim: unsupported header canonicalization: %q", options.HeaderCanonicalization)
+	}
+	switch options.bodyCanonicalization() {
+	case CanonicalizationSimple, CanonicalizationRelaxed:
+	default:
+		return nil, fmt.Errorf("dkim: unsupported body canonicalization: %q", options.BodyCanonicalization)
+	}
+	if !options.hash().Available() {
+		return nil, fmt.Errorf("dkim: unsupported hash algorithm")
+	}
+	if options.AUID != "" {
+		if err := validateAUID(options.AUID, options.Domain); err != nil {
+			return nil, err
+		}
+	}
+
+	algoName, err := signAlgoName(options.Signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{options: options, algoName: algoName}, nil
+}
+
+// Write feeds part of the message to the Signer. The header fields must be
+// written before the body; once the blank line separating them from the
+// body has been seen, subsequent bytes are canonicalized and hashed
+// incrementally without being retained.
+func (s *Signer) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("dkim: write to closed Signer")
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	total := len(p)
+	if !s.headersDone {
+		s.headerBuf.Write(p)
+		idx := bytes.Index(s.headerBuf.Bytes(), []byte("\r\n\r\n"))
+		if idx < 0 {
+			return total, nil
+		}
+
+		buf := s.headerBuf.Bytes()
+		headerPart := append(append([]byte{}, buf[:idx+2]...), '\r', '\n')
+		bodyStart := append([]byte{}, buf[idx+4:]...)
+		s.headerBuf.Reset()
+
+		headers, _, err := readMessage(bytes.NewReader(headerPart))
+		if err != nil {
+			s.err = err
+			return 0, err
+		}
+		if err := s.finishHeaders(headers); err != nil {
+			s.err = err
+			return 0, err
+		}
+		s.headersDone = true
+		p = bodyStart
+	}
+
+	if len(p) > 0 {
+		s.canon.Write(p)
+	}
+	return total, nil
+}
+
+func (s *Signer) finishHeaders(headers []string) error {
+	headerKeys := s.options.HeaderKeys
+	if len(headerKeys) == 0 {
+		for _, raw := range headers {
+			i := strings.IndexByte(raw, ':')
+			if i < 0 {
+				return fmt.Errorf("dkim: malformed header field: %q", raw)
+			}
+			headerKeys = append(headerKeys, strings.TrimSpace(raw[:i]))
+		}
+	}
+	headerKeys = append(append([]string{}, headerKeys...), s.options.OversignHeaderKeys...)
+	if err := requireFromHeader(headerKeys); err != nil {
+		return err
+	}
+
+	fields, err := pickHeaders(headers, headerKeys)
+	if err != nil {
+		return err
+	}
+
+	s.headerKeys = headerKeys
+	s.fields = fields
+	s.canon = newStreamingBodyCanon(s.options.bodyCanonicalization(), s.options.hash(), s.options.BodyLimit)
+	return nil
+}
+
+// Close finalizes the signature. It must be called exactly once, after the
+// entire message has been written.
+func (s *Signer) Close() error {
+	if s.closed {
+		return s.err
+	}
+	s.closed = true
+	if s.err != nil {
+		return s.err
+	}
+	if !s.headersDone {
+		s.err = errors.New("dkim: message ended before its header fields were complete")
+		return s.err
+	}
+
+	s.canon.Close()
+	bh := s.canon.SumBase64()
+
+	hashName := hashAlgoName(s.algoName, s.options.hash())
+	tags := []string{
+		"a=" + hashName,
+		"bh=" + bh,
+		"c=" + s.options.headerCanonicalization() + "/" + s.options.bodyCanonicalization(),
+		"d=" + s.options.Domain,
+		"h=" + strings.Join(s.headerKeys, ":"),
+	}
+	if s.options.AUID != "" {
+		tags = append(tags, "i="+s.options.AUID)
+	}
+	if s.options.BodyLimit > 0 {
+		tags = append(tags, "l="+fmt.Sprint(s.canon.Len()))
+	}
+	tags = append(tags, "s="+s.options.Selector)
+
+	ts, omitTimestamp := s.options.signatureTime()
+	if !omitTimestamp {
+		tags = append(tags, "t="+fmt.Sprint(ts.Unix()))
+	}
+	if s.options.SignatureExpiresIn > 0 {
+		expiresFrom := ts
+		if omitTimestamp {
+			// ts is the zero time when t= is omitted; the expiration is
+			// still relative to now, not to the zero value.
+			expiresFrom = now()
+		}
+		tags = append(tags, "x="+fmt.Sprint(expiresFrom.Add(s.options.SignatureExpiresIn).Unix()))
+	}
+	tags = append(tags, "v=1")
+
+	unsignedValue := " " + strings.Join(tags, "; ") + "; b="
+	sig, err := computeSignature(s.options, s.fields, unsignedValue)
+	if err != nil {
+		s.err = err
+		return err
+	}
+	tags = append(tags, "b="+sig)
+
+	s.sig = foldHeader("DKIM-Signature", " "+strings.Join(tags, "; ")+";")
+	return nil
+}
+
+// Signature returns the folded "DKIM-Signature: ...\r\n" header field
+// computed from the message written to the Signer. It must be called after
+// Close.
+func (s *Signer) Signature() (string, error) {
+	if !s.closed {
+		return "", errors.New("dkim: signer is not closed")
+	}
+	if s.err != nil {
+		return "", s.err
+	}
+	return s.sig, nil
+}
+
+// Writer wraps an io.Writer, computing a DKIM-Signature header field for the
+// message written to it and prepending that header field to the output once
+// the whole message has been seen. The message is spooled to a temporary
+// file while it's hashed, rather than held in memory, so Writer's own memory
+// use stays bounded even for very large messages.
+type Writer struct {
+	w      io.Writer
+	signer *Signer
+	spool  *os.File
+	err    error
+}
+
+// NewWriter creates a Writer that signs the message written to it according
+// to options, writing the signed message to w.
+func NewWriter(w io.Writer, options *SignOptions) (*Writer, error) {
+	signer, err := NewSigner(options)
+	if err != nil {
+		return nil, err
+	}
+	spool, err := os.CreateTemp("", "dkim-sign-*")
+	if err != nil {
+		return nil, fmt.Errorf("dkim: failed to create signing spool file: %w", err)
+	}
+	return &Writer{w: w, signer: signer, spool: spool}, nil
+}
+
+func (sw *Writer) Write(p []byte) (int, error) {
+	if sw.err != nil {
+		return 0, sw.err
+	}
+	if _, err := sw.spool.Write(p); err != nil {
+		sw.err = fmt.Errorf("dkim: failed to spool message: %w", err)
+		return 0, sw.err
+	}
+	return sw.signer.Write(p)
+}
+
+// Close finishes signing the message and writes the DKIM-Signature header
+// field followed by the spooled message to the underlying writer. It
+// removes the temporary spool file regardless of whether signing succeeded.
+func (sw *Writer) Close() error {
+	defer os.Remove(sw.spool.Name())
+	defer sw.spool.Close()
+
+	if sw.err != nil {
+		return sw.err
+	}
+	if err := sw.signer.Close(); err != nil {
+		return err
+	}
+	sig, err := sw.signer.Signature()
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(sw.w, sig); err != nil {
+		return err
+	}
+	if _, err := sw.spool.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	_, err = io.Copy(sw.w, sw.spool)
+	return err
+}
+
+// streamingBodyCanon canonicalizes and hashes a message body incrementally,
+// line by line, retaining only an in-progress line and any run of trailing
+// blank lines that might still turn out to be trimmed, matching
+// canonicalizeBody's behavior without holding the whole body in memory.
+type streamingBodyCanon struct {
+	relaxed bool
+	h       hash.Hash
+
+	pending  []byte
+	blankRun int
+
+	limit   int64
+	written int64
+}
+
+func newStreamingBodyCanon(c string, hashAlgo crypto.Hash, limit int64) *streamingBodyCanon {
+	return &streamingBodyCanon{
+		relaxed: c == CanonicalizationRelaxed,
+		h:       hashAlgo.New(),
+		limit:   limit,
+	}
+}
+
+// Write feeds raw (un-canonicalized) body bytes to the canonicalizer.
+func (c *streamingBodyCanon) Write(p []byte) {
+	c.pending = append(c.pending, p...)
+	for {
+		i := bytes.Index(c.pending, []byte("\r\n"))
+		if i < 0 {
+			break
+		}
+		line := c.pending[:i]
+		c.commitLine(line)
+		c.pending = c.pending[i+2:]
+	}
+}
+
+// Close finalizes canonicalization once the whole body has been written,
+// treating any unterminated trailing bytes as the body's final line, the
+// same way canonicalizeBody treats a body with no trailing CRLF.
+func (c *streamingBodyCanon) Close() {
+	final := c.canonLine(c.pending)
+	c.pending = nil
+	if len(final) == 0 {
+		// Nothing more is coming: this (possibly empty) final fragment and
+		// any still-pending blank lines are trailing, and get trimmed.
+		c.blankRun = 0
+		if !c.relaxed && c.written == 0 {
+			// RFC 6376 Section 3.4.3: a completely empty or missing body is
+			// canonicalized as a single CRLF, matching canonicalizeBody.
+			c.emit([]byte("\r\n"))
+		}
+		return
+	}
+	c.flushBlanks()
+	c.emit(final)
+	c.emit([]byte("\r\n"))
+}
+
+func (c *streamingBodyCanon) commitLine(line []byte) {
+	line = c.canonLine(line)
+	if len(line) == 0 {
+		c.blankRun++
+		return
+	}
+	c.flushBlanks()
+	c.emit(line)
+	c.emit([]byte("\r\n"))
+}
+
+func (c *streamingBodyCanon) canonLine(line []byte) []byte {
+	if !c.relaxed {
+		return line
+	}
+	line = wspRunRegexp.ReplaceAll(line, []byte(" "))
+	return bytes.TrimRight(line, " ")
+}
+
+func (c *streamingBodyCanon) flushBlanks() {
+	for i := 0; i < c.blankRun; i++ {
+		c.emit([]byte("\r\n"))
+	}
+	c.blankRun = 0
+}
+
+// emit hashes b, truncating it if it would push the number of hashed bytes
+// past limit (used to implement the "l=" tag).
+func (c *streamingBodyCanon) emit(b []byte) {
+	if c.limit > 0 {
+		remaining := c.limit - c.written
+		if remaining <= 0 {
+			return
+		}
+		if int64(len(b)) > remaining {
+			b = b[:remaining]
+		}
+	}
+	c.h.Write(b)
+	c.written += int64(len(b))
+}
+
+// SumBase64 returns the base64-encoded hash of the canonicalized body, for
+// the "bh=" tag.
+func (c *streamingBodyCanon) SumBase64() string {
+	return base64.StdEncoding.EncodeToString(c.h.Sum(nil))
+}
+
+// Len returns the number of canonicalized body octets that were hashed, for
+// the "l=" tag.
+func (c *streamingBodyCanon) Len() int64 {
+	return c.written
+}
@@ -0,0 +1,211 @@
+package dkim
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSignAndVerify_bodyLimit(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:    "example.org",
+		Selector:  "brisbane",
+		Signer:    testPrivateKey,
+		BodyLimit: int64(len(mailBodyString) - len("Joe.")),
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+	if !strings.Contains(b.String(), "l=") {
+		t.Errorf("Expected a DKIM-Signature with an l= tag, got:\n%v", b.String())
+	}
+
+	verifications, err := Verify(&b)
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 {
+		t.Fatal("Expected exactly one verification")
+	}
+	if err := verifications[0].Err; err != nil {
+		t.Errorf("Expected no error verifying a body-limited signature, got: %v", err)
+	}
+}
+
+func TestVerify_negativeBodyLimit(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:    "example.org",
+		Selector:  "brisbane",
+		Signer:    testPrivateKey,
+		BodyLimit: int64(len(mailBodyString)),
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+
+	// A forged negative l= tag must be rejected, not panic on a negative
+	// slice bound.
+	signed := regexp.MustCompile(`l=\d+`).ReplaceAllString(b.String(), "l=-5")
+
+	verifications, err := Verify(strings.NewReader(signed))
+	if err != nil {
+		t.Fatalf("Expected no error parsing the message, got: %v", err)
+	}
+	if len(verifications) != 1 {
+		t.Fatal("Expected exactly one verification")
+	}
+	if err := verifications[0].Err; err == nil || !strings.Contains(err.Error(), "l=") {
+		t.Errorf("Expected a malformed l= error for a negative l=, got: %v", err)
+	}
+}
+
+func TestSignAndVerify_emptyBody(t *testing.T) {
+	r := strings.NewReader(mailHeaderString + "\r\n")
+	options := &SignOptions{
+		Domain:   "example.org",
+		Selector: "brisbane",
+		Signer:   testPrivateKey,
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail with an empty body, got:", err)
+	}
+
+	// RFC 6376 Section 3.4.3: an empty body canonicalizes as a single CRLF
+	// under "simple", so bh= must be sha256("\r\n"), not sha256("").
+	const wantBodyHash = "frcCV1k9oG9oKj3dpUqdJg1PxRT2RSN/XKdLCPjaYaY="
+	unfolded := strings.ReplaceAll(b.String(), "\r\n ", "")
+	if !strings.Contains(unfolded, "bh="+wantBodyHash) {
+		t.Errorf("Expected bh=%s for an empty body, got:\n%v", wantBodyHash, b.String())
+	}
+
+	verifications, err := Verify(&b)
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 {
+		t.Fatal("Expected exactly one verification")
+	}
+	if err := verifications[0].Err; err != nil {
+		t.Errorf("Expected no error verifying a signature over an empty body, got: %v", err)
+	}
+}
+
+func TestSignAndVerify_oversign(t *testing.T) {
+	r := strings.NewReader(mailString)
+	options := &SignOptions{
+		Domain:             "example.org",
+		Selector:           "brisbane",
+		Signer:             testPrivateKey,
+		OversignHeaderKeys: []string{"Subject"},
+	}
+
+	var b bytes.Buffer
+	if err := Sign(&b, r, options); err != nil {
+		t.Fatal("Expected no error while signing mail, got:", err)
+	}
+	signed := b.String()
+	unfolded := strings.ReplaceAll(signed, "\r\n ", "")
+	if !strings.Contains(unfolded, "h=From:To:Subject:Date:Message-ID:Subject;") {
+		t.Errorf("Expected Subject to appear twice in h=, got:\n%v", signed)
+	}
+
+	verifications, err := Verify(strings.NewReader(signed))
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 || verifications[0].Err != nil {
+		t.Fatalf("Expected a valid verification, got: %+v", verifications)
+	}
+
+	// Adding a second Subject header field, which an oversigned signature
+	// guards against, must now invalidate the signature.
+	tampered := strings.Replace(signed, "Subject: Is dinner ready?\r\n",
+		"Subject: Is dinner ready?\r\nSubject: injected\r\n", 1)
+	verifications, err = Verify(strings.NewReader(tampered))
+	if err != nil {
+		t.Fatalf("Expected no error while verifying signature, got: %v", err)
+	}
+	if len(verifications) != 1 || verifications[0].Err == nil {
+		t.Error("Expected the oversigned signature to be invalidated by an injected Subject header field")
+	}
+}
+
+// hugeBody is an io.Reader that produces n bytes of a repeating,
+// line-terminated pattern without ever holding more than one line in
+// memory, used to prove that signing doesn't materialize the whole body.
+type hugeBody struct {
+	remaining int64
+	line      []byte
+	pos       int
+}
+
+func newHugeBody(n int64) *hugeBody {
+	return &hugeBody{remaining: n, line: []byte("The quick brown fox jumps over the lazy dog.\r\n")}
+}
+
+func (h *hugeBody) Read(p []byte) (int, error) {
+	if h.remaining <= 0 {
+		return 0, io.EOF
+	}
+	n := 0
+	for n < len(p) && h.remaining > 0 {
+		if h.pos == len(h.line) {
+			h.pos = 0
+		}
+		c := copy(p[n:], h.line[h.pos:])
+		if int64(c) > h.remaining {
+			c = int(h.remaining)
+		}
+		n += c
+		h.pos += c
+		h.remaining -= int64(c)
+	}
+	return n, nil
+}
+
+func TestSign_largeBody(t *testing.T) {
+	const bodySize = 1 << 30 // 1 GiB
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer pw.Close()
+		fmt.Fprint(pw, mailHeaderString)
+		fmt.Fprint(pw, "\r\n")
+		io.Copy(pw, newHugeBody(bodySize))
+	}()
+
+	options := &SignOptions{
+		Domain:   "example.org",
+		Selector: "brisbane",
+		Signer:   testPrivateKey,
+	}
+
+	signer, err := NewSigner(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(signer, pr); err != nil {
+		t.Fatal("Expected no error while feeding a large body to the Signer, got:", err)
+	}
+	if err := signer.Close(); err != nil {
+		t.Fatal("Expected no error while closing the Signer, got:", err)
+	}
+	sig, err := signer.Signature()
+	if err != nil {
+		t.Fatal("Expected no error while retrieving the signature, got:", err)
+	}
+	if !strings.HasPrefix(sig, "DKIM-Signature:") {
+		t.Errorf("Expected a DKIM-Signature header field, got:\n%v", sig)
+	}
+}
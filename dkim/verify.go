@@ -0,0 +1,320 @@
+package dkim
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VerifyOptions is used to configure VerifyWithOptions.
+type VerifyOptions struct {
+	// Resolver is used to look up DKIM public key records. Defaults to
+	// DefaultResolver.
+	Resolver Resolver
+}
+
+// Verify checks the DKIM signatures in a message read from r, fetching
+// public keys over DNS as needed. It returns one Verification per
+// DKIM-Signature header field found in the message. It's a shorthand for
+// VerifyWithOptions with a background context and the default resolver.
+func Verify(r io.Reader) ([]*Verification, error) {
+	return VerifyWithOptions(context.Background(), r, nil)
+}
+
+// VerifyWithOptions checks the DKIM signatures in a message read from r,
+// like Verify, using options to control how public keys are resolved.
+func VerifyWithOptions(ctx context.Context, r io.Reader, options *VerifyOptions) ([]*Verification, error) {
+	resolver := DefaultResolver
+	if options != nil && options.Resolver != nil {
+		resolver = options.Resolver
+	}
+
+	headers, body, err := readMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var verifications []*Verification
+	for _, raw := range headers {
+		name, err := headerName(raw)
+		if err != nil {
+			return nil, err
+		}
+		if name != "dkim-signature" {
+			continue
+		}
+		verifications = append(verifications, verifyOne(ctx, resolver, raw, headers, body))
+	}
+	return verifications, nil
+}
+
+func verifyOne(ctx context.Context, resolver Resolver, raw string, headers []string, body []byte) *Verification {
+	tags, err := parseTagList(raw)
+	if err != nil {
+		return &Verification{Err: err}
+	}
+
+	v := &Verification{
+		Domain:     tags["d"],
+		Identifier: tags["i"],
+	}
+	if hs, ok := tags["h"]; ok {
+		v.HeaderKeys = strings.Split(hs, ":")
+	}
+	if t, ok := tags["t"]; ok {
+		if sec, err := strconv.ParseInt(t, 10, 64); err == nil {
+			v.Timestamp = time.Unix(sec, 0)
+		}
+	}
+	if x, ok := tags["x"]; ok {
+		if sec, err := strconv.ParseInt(x, 10, 64); err == nil {
+			v.ExpiresAt = time.Unix(sec, 0)
+		}
+	}
+
+	ad, err := verifySignature(ctx, resolver, raw, tags, headers, body)
+	v.AuthenticatedData = ad
+	if err != nil {
+		v.Err = err
+	}
+	return v
+}
+
+func verifySignature(ctx context.Context, resolver Resolver, raw string, tags map[string]string, headers []string, body []byte) (bool, error) {
+	if tags["v"] != "1" {
+		return false, fmt.Errorf("dkim: unsupported version: %q", tags["v"])
+	}
+	domain, ok := tags["d"]
+	if !ok || domain == "" {
+		return false, fmt.Errorf("dkim: missing d= tag")
+	}
+	selector, ok := tags["s"]
+	if !ok || selector == "" {
+		return false, fmt.Errorf("dkim: missing s= tag")
+	}
+	algo, hashAlgo, err := splitAlgo(tags["a"])
+	if err != nil {
+		return false, err
+	}
+	headerC, bodyC, err := splitCanon(tags["c"])
+	if err != nil {
+		return false, err
+	}
+	headerKeys, ok := tags["h"]
+	if !ok || headerKeys == "" {
+		return false, fmt.Errorf("dkim: missing h= tag")
+	}
+	bh, ok := tags["bh"]
+	if !ok {
+		return false, fmt.Errorf("dkim: missing bh= tag")
+	}
+	b, ok := tags["b"]
+	if !ok {
+		return false, fmt.Errorf("dkim: missing b= tag")
+	}
+	if x, ok := tags["x"]; ok {
+		sec, err := strconv.ParseInt(x, 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("dkim: malformed x= tag: %w", err)
+		}
+		if now().After(time.Unix(sec, 0)) {
+			return false, fmt.Errorf("dkim: signature has expired")
+		}
+	}
+
+	hash, err := parseHashAlgo(hashAlgo)
+	if err != nil {
+		return false, err
+	}
+
+	canonBody, err := canonicalizeBody(bodyC, body)
+	if err != nil {
+		return false, err
+	}
+	if l, ok := tags["l"]; ok {
+		limit, err := strconv.ParseInt(l, 10, 64)
+		if err != nil || limit < 0 {
+			return false, fmt.Errorf("dkim: malformed l= tag: %q", l)
+		}
+		if limit < int64(len(canonBody)) {
+			canonBody = canonBody[:limit]
+		}
+	}
+	h := hash.New()
+	h.Write(canonBody)
+	if base64.StdEncoding.EncodeToString(h.Sum(nil)) != bh {
+		return false, fmt.Errorf("dkim: body hash does not match")
+	}
+
+	fields, err := pickHeaders(headers, strings.Split(headerKeys, ":"))
+	if err != nil {
+		return false, err
+	}
+
+	unsignedRaw, err := unsignHeader(raw, b)
+	if err != nil {
+		return false, err
+	}
+	data, err := buildSignedData(headerC, fields, unsignedRaw)
+	if err != nil {
+		return false, err
+	}
+
+	pub, keyAlgo, ad, err := lookupPublicKey(ctx, resolver, domain, selector)
+	if err != nil {
+		return ad, err
+	}
+	if keyAlgo != algo {
+		return ad, fmt.Errorf("dkim: key algorithm %q does not match signature algorithm %q", keyAlgo, algo)
+	}
+
+	return ad, verifyDigest(pub, hash, data, b)
+}
+
+// unsignHeader returns the raw, unfolded DKIM-Signature header field with the
+// value of its b= tag cleared, matching what the signer hashed.
+func unsignHeader(raw string, b string) (string, error) {
+	unfolded := unfoldHeader(raw)
+	idx := strings.Index(unfolded, "b="+b)
+	if idx < 0 {
+		return "", fmt.Errorf("dkim: could not locate b= tag value")
+	}
+	return unfolded[:idx+len("b=")], nil
+}
+
+func unfoldHeader(raw string) string {
+	return strings.ReplaceAll(raw, "\r\n ", "")
+}
+
+func parseTagList(raw string) (map[string]string, error) {
+	i := strings.IndexByte(raw, ':')
+	if i < 0 {
+		return nil, fmt.Errorf("dkim: malformed header field: %q", raw)
+	}
+	value := unfoldHeader(raw[i+1:])
+	value = strings.TrimSuffix(strings.TrimSpace(value), ";")
+
+	tags := make(map[string]string)
+	for _, part := range strings.Split(value, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("dkim: malformed tag: %q", part)
+		}
+		tags[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return tags, nil
+}
+
+func splitAlgo(a string) (algo, hashAlgo string, err error) {
+	parts := strings.SplitN(a, "-", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("dkim: malformed a= tag: %q", a)
+	}
+	return parts[0], parts[1], nil
+}
+
+func splitCanon(c string) (header, body string, err error) {
+	if c == "" {
+		return CanonicalizationSimple, CanonicalizationSimple, nil
+	}
+	parts := strings.SplitN(c, "/", 2)
+	switch len(parts) {
+	case 1:
+		return parts[0], CanonicalizationSimple, nil
+	case 2:
+		return parts[0], parts[1], nil
+	default:
+		return "", "", fmt.Errorf("dkim: malformed c= tag: %q", c)
+	}
+}
+
+func parseHashAlgo(name string) (crypto.Hash, error) {
+	switch name {
+	case "sha1":
+		return crypto.SHA1, nil
+	case "sha256":
+		return crypto.SHA256, nil
+	default:
+		return 0, fmt.Errorf("dkim: unsupported hash algorithm: %q", name)
+	}
+}
+
+// lookupPublicKey fetches and parses the public key published at
+// selector._domainkey.domain, reporting whether the DNS answer was
+// DNSSEC-authenticated.
+func lookupPublicKey(ctx context.Context, resolver Resolver, domain, selector string) (crypto.PublicKey, string, bool, error) {
+	name := selector + "._domainkey." + domain
+	txts, ad, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return nil, "", ad, fmt.Errorf("dkim: key lookup failed: %w", err)
+	}
+	if len(txts) == 0 {
+		return nil, "", ad, fmt.Errorf("dkim: no key found for %s", name)
+	}
+
+	tags, err := parseKeyRecord(strings.Join(txts, ""))
+	if err != nil {
+		return nil, "", ad, err
+	}
+
+	algo := tags["k"]
+	if algo == "" {
+		algo = algoRSA
+	}
+	p := tags["p"]
+	if p == "" {
+		return nil, "", ad, fmt.Errorf("dkim: key record for %s has been revoked", name)
+	}
+	raw, err := base64.StdEncoding.DecodeString(p)
+	if err != nil {
+		return nil, "", ad, fmt.Errorf("dkim: malformed p= tag: %w", err)
+	}
+
+	switch algo {
+	case algoEd25519:
+		if len(raw) != ed25519.PublicKeySize {
+			return nil, "", ad, fmt.Errorf("dkim: invalid ed25519 public key length")
+		}
+		return ed25519.PublicKey(raw), algo, ad, nil
+	case algoRSA:
+		pub, err := x509.ParsePKIXPublicKey(raw)
+		if err != nil {
+			return nil, "", ad, fmt.Errorf("dkim: malformed RSA public key: %w", err)
+		}
+		rsaPub, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return nil, "", ad, fmt.Errorf("dkim: key record does not contain an RSA public key")
+		}
+		return rsaPub, algo, ad, nil
+	default:
+		return nil, "", ad, fmt.Errorf("dkim: unsupported key algorithm: %q", algo)
+	}
+}
+
+func parseKeyRecord(s string) (map[string]string, error) {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("dkim: malformed key record tag: %q", part)
+		}
+		tags[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return tags, nil
+}
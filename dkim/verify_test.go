@@ -0,0 +1,23 @@
+package dkim
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+)
+
+func init() {
+	rsaPub, err := x509.MarshalPKIXPublicKey(&testPrivateKey.PublicKey)
+	if err != nil {
+		panic(err)
+	}
+	rsaRecord := "v=DKIM1; k=rsa; p=" + base64.StdEncoding.EncodeToString(rsaPub)
+	ed25519Record := "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(testEd25519PublicKey)
+
+	DefaultResolver = MapResolver{
+		Records: map[string][]string{
+			"brisbane._domainkey.example.org":      {rsaRecord},
+			"test._domainkey.football.example.com": {ed25519Record},
+		},
+		AD: true,
+	}
+}